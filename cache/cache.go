@@ -0,0 +1,94 @@
+// Package cache implements a content-addressable store for the formatted
+// .dhall output produced by dhallie's templates, so unchanged templates can
+// be skipped on subsequent runs.
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Store is a directory-backed cache keyed by an opaque string, mirroring the
+// tag/item/create-callback shape of a classic CAS: callers ask for an item
+// by key and supply a produce callback to fill the cache on a miss.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a Store backed by dir, creating it if necessary.
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:]))
+}
+
+// GetOrCreate returns the cached bytes for key, calling produce to fill the
+// cache on a miss. produce writes the item's contents to w; whatever it
+// writes is what GetOrCreate returns and persists for future calls.
+func (s *Store) GetOrCreate(key string, produce func(w io.Writer) error) ([]byte, error) {
+	p := s.path(key)
+
+	if b, err := ioutil.ReadFile(p); err == nil {
+		return b, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := produce(&buf); err != nil {
+		return nil, err
+	}
+
+	tmp := p + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Prune removes cache entries that have not been modified within ttl.
+func (s *Store) Prune(ttl time.Duration) error {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-ttl)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if entry.ModTime().Before(cutoff) {
+			if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DefaultDir returns the default cache directory: $XDG_CACHE_HOME/dhallie,
+// falling back to the OS's standard user cache directory.
+func DefaultDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "dhallie"), nil
+}
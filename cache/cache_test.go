@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetOrCreateMissThenHit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-test-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	calls := 0
+	produce := func(w io.Writer) error {
+		calls++
+		_, err := w.Write([]byte("rendered"))
+		return err
+	}
+
+	b, err := store.GetOrCreate("key", produce)
+	if err != nil {
+		t.Fatalf("GetOrCreate() (miss) error = %v", err)
+	}
+	if string(b) != "rendered" {
+		t.Fatalf("GetOrCreate() (miss) = %q, want %q", b, "rendered")
+	}
+	if calls != 1 {
+		t.Fatalf("produce called %d times on miss, want 1", calls)
+	}
+
+	b, err = store.GetOrCreate("key", produce)
+	if err != nil {
+		t.Fatalf("GetOrCreate() (hit) error = %v", err)
+	}
+	if string(b) != "rendered" {
+		t.Fatalf("GetOrCreate() (hit) = %q, want %q", b, "rendered")
+	}
+	if calls != 1 {
+		t.Fatalf("produce called %d times after a hit, want 1 (cache should not re-run produce)", calls)
+	}
+}
+
+func TestGetOrCreateDistinctKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-test-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	produce := func(content string) func(w io.Writer) error {
+		return func(w io.Writer) error {
+			_, err := w.Write([]byte(content))
+			return err
+		}
+	}
+
+	a, err := store.GetOrCreate("a", produce("a-content"))
+	if err != nil {
+		t.Fatalf("GetOrCreate(a) error = %v", err)
+	}
+	b, err := store.GetOrCreate("b", produce("b-content"))
+	if err != nil {
+		t.Fatalf("GetOrCreate(b) error = %v", err)
+	}
+	if string(a) == string(b) {
+		t.Fatalf("distinct keys produced the same content: %q", a)
+	}
+}
+
+func TestGetOrCreateProduceError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-test-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	wantErr := errors.New("boom")
+	_, err = store.GetOrCreate("key", func(w io.Writer) error {
+		return wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("GetOrCreate() error = %v, want %v", err, wantErr)
+	}
+
+	if _, err := os.Stat(store.path("key")); !os.IsNotExist(err) {
+		t.Fatalf("GetOrCreate() left a cache entry behind after produce failed")
+	}
+}
+
+func TestPruneRemovesOnlyStaleEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-test-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("NewStore() error = %v", err)
+	}
+
+	stalePath := filepath.Join(dir, "stale")
+	freshPath := filepath.Join(dir, "fresh")
+	if err := ioutil.WriteFile(stalePath, []byte("old"), 0644); err != nil {
+		t.Fatalf("failed to write stale entry: %v", err)
+	}
+	if err := ioutil.WriteFile(freshPath, []byte("new"), 0644); err != nil {
+		t.Fatalf("failed to write fresh entry: %v", err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(stalePath, old, old); err != nil {
+		t.Fatalf("failed to backdate stale entry: %v", err)
+	}
+
+	if err := store.Prune(24 * time.Hour); err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+
+	if _, err := os.Stat(stalePath); !os.IsNotExist(err) {
+		t.Fatalf("Prune() did not remove the stale entry")
+	}
+	if _, err := os.Stat(freshPath); err != nil {
+		t.Fatalf("Prune() removed the fresh entry: %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Package components walks the component -> kind -> name -> containers ->
+// containerName shape of a dhallie components file into the flat tuples a
+// .dhall-template range-loops over. It is split out from main so other Go
+// programs can resolve the same TemplateData a template would see without
+// reimplementing the YAML walk.
+package components
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ContainerTuple is one component/kind/name/container combination, with a
+// stable per-run Identifier templates can use as a dhall record field name.
+type ContainerTuple struct {
+	Component     string `json:"component" yaml:"component"`
+	Name          string `json:"name" yaml:"name"`
+	ContainerName string `json:"containerName" yaml:"containerName"`
+	Identifier    string `json:"identifier" yaml:"identifier"`
+}
+
+// KindTuple is one component/kind/name combination, regardless of kind.
+type KindTuple struct {
+	Component  string `json:"component" yaml:"component"`
+	Name       string `json:"name" yaml:"name"`
+	Kind       string `json:"kind" yaml:"kind"`
+	Identifier string `json:"identifier" yaml:"identifier"`
+}
+
+// TemplateData is everything a .dhall-template can range over: containers
+// belonging to Deployments and StatefulSets, and every component/kind/name
+// regardless of kind.
+type TemplateData struct {
+	DeploymentTuples  []*ContainerTuple `json:"deploymentTuples" yaml:"deploymentTuples"`
+	StatefulSetTuples []*ContainerTuple `json:"statefulSetTuples" yaml:"statefulSetTuples"`
+	KindTuples        []*KindTuple      `json:"kindTuples" yaml:"kindTuples"`
+}
+
+// ContainerTuples walks comps for every container belonging to a name of
+// the given kind (e.g. "Deployment", "StatefulSet"). The result is sorted
+// by component/name/containerName (comps is a map, so range order alone is
+// randomized per run) and Identifier is assigned after sorting, so the same
+// input always yields byte-identical output — callers such as
+// templateCacheKey hash this output as part of a cache key.
+func ContainerTuples(targetKind string, comps map[string]interface{}) []*ContainerTuple {
+	var result []*ContainerTuple
+
+	for comp, compData := range comps {
+		compDataM, ok := compData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for kind, kindData := range compDataM {
+			if kind != targetKind {
+				continue
+			}
+			kindDataM, ok := kindData.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			for name, nameData := range kindDataM {
+				nameDataM, ok := nameData.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				for section, sectionData := range nameDataM {
+					if section != "containers" {
+						continue
+					}
+
+					sectionDataM, ok := sectionData.(map[string]interface{})
+					if !ok {
+						continue
+					}
+
+					for containerName := range sectionDataM {
+						result = append(result, &ContainerTuple{
+							Component:     comp,
+							Name:          name,
+							ContainerName: containerName,
+						})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Component != b.Component {
+			return a.Component < b.Component
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ContainerName < b.ContainerName
+	})
+	for i, t := range result {
+		t.Identifier = fmt.Sprintf("f%d", i)
+	}
+	return result
+}
+
+// KindTuples walks comps for every component/kind/name combination. Like
+// ContainerTuples, the result is sorted before Identifier is assigned so
+// repeated calls over the same input are byte-identical.
+func KindTuples(comps map[string]interface{}) []*KindTuple {
+	var result []*KindTuple
+
+	for comp, compData := range comps {
+		compDataM, ok := compData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for kind, kindData := range compDataM {
+			kindDataM, ok := kindData.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			for name := range kindDataM {
+				result = append(result, &KindTuple{
+					Component: comp,
+					Kind:      kind,
+					Name:      name,
+				})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Component != b.Component {
+			return a.Component < b.Component
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	for i, t := range result {
+		t.Identifier = fmt.Sprintf("f%d", i)
+	}
+	return result
+}
+
+// Resolve builds the full TemplateData a .dhall-template sees from a
+// decoded components file.
+func Resolve(comps map[string]interface{}) *TemplateData {
+	return &TemplateData{
+		DeploymentTuples:  ContainerTuples("Deployment", comps),
+		StatefulSetTuples: ContainerTuples("StatefulSet", comps),
+		KindTuples:        KindTuples(comps),
+	}
+}
@@ -0,0 +1,105 @@
+package components
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testComps() map[string]interface{} {
+	return map[string]interface{}{
+		"web": map[string]interface{}{
+			"Deployment": map[string]interface{}{
+				"api": map[string]interface{}{
+					"containers": map[string]interface{}{
+						"sidecar": map[string]interface{}{},
+						"app":     map[string]interface{}{},
+					},
+				},
+			},
+			"ConfigMap": map[string]interface{}{
+				"config": map[string]interface{}{},
+			},
+		},
+		"cache": map[string]interface{}{
+			"StatefulSet": map[string]interface{}{
+				"redis": map[string]interface{}{
+					"containers": map[string]interface{}{
+						"redis": map[string]interface{}{},
+					},
+				},
+			},
+		},
+	}
+}
+
+func derefContainerTuples(in []*ContainerTuple) []ContainerTuple {
+	out := make([]ContainerTuple, len(in))
+	for i, p := range in {
+		out[i] = *p
+	}
+	return out
+}
+
+func derefKindTuples(in []*KindTuple) []KindTuple {
+	out := make([]KindTuple, len(in))
+	for i, p := range in {
+		out[i] = *p
+	}
+	return out
+}
+
+func TestContainerTuplesIsSortedAndIdentified(t *testing.T) {
+	got := derefContainerTuples(ContainerTuples("Deployment", testComps()))
+
+	want := []ContainerTuple{
+		{Component: "web", Name: "api", ContainerName: "app", Identifier: "f0"},
+		{Component: "web", Name: "api", ContainerName: "sidecar", Identifier: "f1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ContainerTuples(\"Deployment\", comps) = %+v, want %+v", got, want)
+	}
+}
+
+func TestContainerTuplesUnknownKind(t *testing.T) {
+	if got := ContainerTuples("Ingress", testComps()); len(got) != 0 {
+		t.Errorf("ContainerTuples(\"Ingress\", comps) = %+v, want empty", derefContainerTuples(got))
+	}
+}
+
+func TestKindTuplesIsSortedAndIdentified(t *testing.T) {
+	got := derefKindTuples(KindTuples(testComps()))
+
+	want := []KindTuple{
+		{Component: "cache", Kind: "StatefulSet", Name: "redis", Identifier: "f0"},
+		{Component: "web", Kind: "ConfigMap", Name: "config", Identifier: "f1"},
+		{Component: "web", Kind: "Deployment", Name: "api", Identifier: "f2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("KindTuples(comps) = %+v, want %+v", got, want)
+	}
+}
+
+func TestContainerTuplesDeterministicAcrossCalls(t *testing.T) {
+	comps := testComps()
+	first := derefContainerTuples(ContainerTuples("Deployment", comps))
+	for i := 0; i < 20; i++ {
+		again := derefContainerTuples(ContainerTuples("Deployment", comps))
+		if !reflect.DeepEqual(first, again) {
+			t.Fatalf("ContainerTuples(comps) is not deterministic across calls:\n%+v\nvs\n%+v", first, again)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	data := Resolve(testComps())
+
+	if len(data.DeploymentTuples) != 2 {
+		t.Errorf("len(DeploymentTuples) = %d, want 2", len(data.DeploymentTuples))
+	}
+	if len(data.StatefulSetTuples) != 1 {
+		t.Errorf("len(StatefulSetTuples) = %d, want 1", len(data.StatefulSetTuples))
+	}
+	if len(data.KindTuples) != 3 {
+		t.Errorf("len(KindTuples) = %d, want 3", len(data.KindTuples))
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sourceExcerpt renders a few lines of raw around line (1-indexed), with a
+// caret under col (1-indexed, 0 meaning "unknown"), for error messages that
+// would otherwise be a bare Go error string.
+func sourceExcerpt(raw []byte, line, col, context int) string {
+	if line <= 0 {
+		return ""
+	}
+
+	lines := strings.Split(string(raw), "\n")
+
+	start := line - context
+	if start < 1 {
+		start = 1
+	}
+	end := line + context
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "%4d | %s\n", i, lines[i-1])
+		if i == line && col > 0 {
+			b.WriteString(strings.Repeat(" ", 7+col-1) + "^\n")
+		}
+	}
+	return b.String()
+}
+
+var yamlErrorLineRe = regexp.MustCompile(`line (\d+)`)
+
+// yamlErrorLine pulls the 1-indexed line number out of a yaml.v3 error
+// message, e.g. "yaml: line 4: mapping values are not allowed in this context".
+func yamlErrorLine(msg string) int {
+	m := yamlErrorLineRe.FindStringSubmatch(msg)
+	if m == nil {
+		return 0
+	}
+	line, _ := strconv.Atoi(m[1])
+	return line
+}
+
+// wrapYAMLError attaches a source excerpt to a yaml.v3 decode error so the
+// offending line is visible without opening the file.
+func wrapYAMLError(filename string, raw []byte, err error) error {
+	line := 0
+	if te, ok := err.(*yaml.TypeError); ok && len(te.Errors) > 0 {
+		line = yamlErrorLine(te.Errors[0])
+	} else {
+		line = yamlErrorLine(err.Error())
+	}
+
+	excerpt := sourceExcerpt(raw, line, 0, 2)
+	if excerpt == "" {
+		return fmt.Errorf("failed to decode yaml file %s: %v", filename, err)
+	}
+	return fmt.Errorf("failed to decode yaml file %s:\n%s%v", filename, excerpt, err)
+}
+
+var templateErrorLocationRe = regexp.MustCompile(`template: [^:]+:(\d+)(?::(\d+))?:`)
+
+// templateErrorLocation pulls the line and, when present, column out of the
+// "template: name:line:col:" prefix text/template puts on parse and
+// execution errors.
+func templateErrorLocation(msg string) (line, col int, ok bool) {
+	m := templateErrorLocationRe.FindStringSubmatch(msg)
+	if m == nil {
+		return 0, 0, false
+	}
+	line, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		col, _ = strconv.Atoi(m[2])
+	}
+	return line, col, true
+}
+
+// wrapTemplateError attaches a source excerpt of templatePath to a
+// text/template parse or execution error, using the line:col it embeds in
+// its own message.
+func wrapTemplateError(templatePath string, raw []byte, err error) error {
+	line, col, ok := templateErrorLocation(err.Error())
+	if !ok {
+		return fmt.Errorf("failed to process template %s: %v", templatePath, err)
+	}
+
+	excerpt := sourceExcerpt(raw, line, col, 2)
+	return fmt.Errorf("failed to process template %s:%d:%d:\n%s%v", templatePath, line, col, excerpt, err)
+}
+
+// componentsDiagnostic pinpoints a part of the components YAML that
+// doesn't match the component -> kind -> name -> containers -> containerName
+// shape components.ContainerTuples and components.KindTuples assume.
+type componentsDiagnostic struct {
+	Path   string
+	Line   int
+	Column int
+	Issue  string
+}
+
+func (d componentsDiagnostic) String() string {
+	return fmt.Sprintf("%s (line %d, column %d): %s", d.Path, d.Line, d.Column, d.Issue)
+}
+
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, bool) {
+	if node.Kind != yaml.MappingNode {
+		return nil, false
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1], true
+		}
+	}
+	return nil, false
+}
+
+// validateComponents walks the same component -> kind -> name -> containers
+// -> containerName shape that components.ContainerTuples/KindTuples assume, and
+// reports every place that shape is violated instead of letting the ok
+// guards in those functions skip it silently.
+func validateComponents(root *yaml.Node) []componentsDiagnostic {
+	var diags []componentsDiagnostic
+
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return diags
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		diags = append(diags, componentsDiagnostic{Line: doc.Line, Column: doc.Column, Issue: "top-level components document must be a mapping of component name to kinds"})
+		return diags
+	}
+
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		compKey, compVal := doc.Content[i], doc.Content[i+1]
+		if compVal.Kind != yaml.MappingNode {
+			diags = append(diags, componentsDiagnostic{Path: compKey.Value, Line: compVal.Line, Column: compVal.Column, Issue: "component must map kind names to names"})
+			continue
+		}
+
+		for j := 0; j+1 < len(compVal.Content); j += 2 {
+			kindKey, kindVal := compVal.Content[j], compVal.Content[j+1]
+			path := compKey.Value + "." + kindKey.Value
+			if kindVal.Kind != yaml.MappingNode {
+				diags = append(diags, componentsDiagnostic{Path: path, Line: kindVal.Line, Column: kindVal.Column, Issue: "kind must map names to their spec"})
+				continue
+			}
+
+			for k := 0; k+1 < len(kindVal.Content); k += 2 {
+				nameKey, nameVal := kindVal.Content[k], kindVal.Content[k+1]
+				namePath := path + "." + nameKey.Value
+				if nameVal.Kind != yaml.MappingNode {
+					diags = append(diags, componentsDiagnostic{Path: namePath, Line: nameVal.Line, Column: nameVal.Column, Issue: "name must map sections such as containers to their spec"})
+					continue
+				}
+
+				containers, ok := mappingValue(nameVal, "containers")
+				if !ok {
+					continue
+				}
+				if containers.Kind != yaml.MappingNode {
+					diags = append(diags, componentsDiagnostic{Path: namePath + ".containers", Line: containers.Line, Column: containers.Column, Issue: "containers must map container names to their spec"})
+				}
+			}
+		}
+	}
+
+	return diags
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestSourceExcerpt(t *testing.T) {
+	raw := []byte("one\ntwo\nthree\nfour\nfive")
+
+	tests := []struct {
+		name    string
+		line    int
+		col     int
+		context int
+		want    string
+	}{
+		{
+			name:    "zero line returns empty",
+			line:    0,
+			col:     0,
+			context: 2,
+			want:    "",
+		},
+		{
+			name:    "middle line with caret",
+			line:    3,
+			col:     2,
+			context: 1,
+			want:    "   2 | two\n   3 | three\n        ^\n   4 | four\n",
+		},
+		{
+			name:    "no caret when col is zero",
+			line:    3,
+			col:     0,
+			context: 1,
+			want:    "   2 | two\n   3 | three\n   4 | four\n",
+		},
+		{
+			name:    "context clamps to file bounds",
+			line:    1,
+			col:     0,
+			context: 5,
+			want:    "   1 | one\n   2 | two\n   3 | three\n   4 | four\n   5 | five\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sourceExcerpt(raw, tt.line, tt.col, tt.context)
+			if got != tt.want {
+				t.Errorf("sourceExcerpt(%d, %d, %d) = %q, want %q", tt.line, tt.col, tt.context, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYamlErrorLine(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want int
+	}{
+		{
+			name: "standard yaml.v3 message",
+			msg:  "yaml: line 4: mapping values are not allowed in this context",
+			want: 4,
+		},
+		{
+			name: "no line number",
+			msg:  "yaml: unexpected end of stream",
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := yamlErrorLine(tt.msg); got != tt.want {
+				t.Errorf("yamlErrorLine(%q) = %d, want %d", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateErrorLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		wantLine int
+		wantCol  int
+		wantOK   bool
+	}{
+		{
+			name:     "line and column",
+			msg:      "template: deploy.dhall-template:5:10: executing \"deploy.dhall-template\" at <.Bogus>: map has no entry for key \"Bogus\"",
+			wantLine: 5,
+			wantCol:  10,
+			wantOK:   true,
+		},
+		{
+			name:     "line only",
+			msg:      "template: deploy.dhall-template:7: unexpected \"}\" in operand",
+			wantLine: 7,
+			wantCol:  0,
+			wantOK:   true,
+		},
+		{
+			name:   "no template prefix",
+			msg:    "some other error",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, col, ok := templateErrorLocation(tt.msg)
+			if ok != tt.wantOK || line != tt.wantLine || col != tt.wantCol {
+				t.Errorf("templateErrorLocation(%q) = (%d, %d, %v), want (%d, %d, %v)", tt.msg, line, col, ok, tt.wantLine, tt.wantCol, tt.wantOK)
+			}
+		})
+	}
+}
+
+func parseYAMLNode(t *testing.T, raw string) *yaml.Node {
+	t.Helper()
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(raw), &root); err != nil {
+		t.Fatalf("failed to parse test yaml: %v", err)
+	}
+	return &root
+}
+
+func TestMappingValue(t *testing.T) {
+	root := parseYAMLNode(t, "containers:\n  app: {}\n")
+	doc := root.Content[0]
+
+	containers, ok := mappingValue(doc, "containers")
+	if !ok {
+		t.Fatalf("mappingValue(doc, %q) ok = false, want true", "containers")
+	}
+	if containers.Kind != yaml.MappingNode {
+		t.Errorf("mappingValue(doc, %q) node kind = %v, want MappingNode", "containers", containers.Kind)
+	}
+
+	if _, ok := mappingValue(doc, "missing"); ok {
+		t.Errorf("mappingValue(doc, %q) ok = true, want false", "missing")
+	}
+
+	scalar := parseYAMLNode(t, "just-a-string\n").Content[0]
+	if _, ok := mappingValue(scalar, "anything"); ok {
+		t.Errorf("mappingValue() on a non-mapping node ok = true, want false")
+	}
+}
+
+func TestValidateComponents(t *testing.T) {
+	tests := []struct {
+		name      string
+		yaml      string
+		wantPaths []string
+		wantIssue string
+	}{
+		{
+			name: "well-formed shape produces no diagnostics",
+			yaml: "web:\n  Deployment:\n    api:\n      containers:\n        app: {}\n",
+		},
+		{
+			name:      "top-level document is not a mapping",
+			yaml:      "- a\n- b\n",
+			wantPaths: []string{""},
+			wantIssue: "top-level components document must be a mapping of component name to kinds",
+		},
+		{
+			name:      "component does not map to kinds",
+			yaml:      "web: [\"not\", \"a\", \"mapping\"]\n",
+			wantPaths: []string{"web"},
+			wantIssue: "component must map kind names to names",
+		},
+		{
+			name:      "kind does not map to names",
+			yaml:      "web:\n  Deployment: [\"not\", \"a\", \"mapping\"]\n",
+			wantPaths: []string{"web.Deployment"},
+			wantIssue: "kind must map names to their spec",
+		},
+		{
+			name:      "name does not map to sections",
+			yaml:      "web:\n  Deployment:\n    api: [\"not\", \"a\", \"mapping\"]\n",
+			wantPaths: []string{"web.Deployment.api"},
+			wantIssue: "name must map sections such as containers to their spec",
+		},
+		{
+			name:      "containers does not map to container names",
+			yaml:      "web:\n  Deployment:\n    api:\n      containers: [\"not\", \"a\", \"mapping\"]\n",
+			wantPaths: []string{"web.Deployment.api.containers"},
+			wantIssue: "containers must map container names to their spec",
+		},
+		{
+			name: "a name section without containers is not flagged",
+			yaml: "web:\n  Deployment:\n    api:\n      labels:\n        tier: frontend\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := parseYAMLNode(t, tt.yaml)
+			diags := validateComponents(root)
+
+			if tt.wantPaths == nil {
+				if len(diags) != 0 {
+					t.Fatalf("validateComponents() = %v, want no diagnostics", diags)
+				}
+				return
+			}
+
+			if len(diags) != len(tt.wantPaths) {
+				t.Fatalf("validateComponents() returned %d diagnostics, want %d: %v", len(diags), len(tt.wantPaths), diags)
+			}
+			for i, want := range tt.wantPaths {
+				if diags[i].Path != want {
+					t.Errorf("diags[%d].Path = %q, want %q", i, diags[i].Path, want)
+				}
+				if diags[i].Issue != tt.wantIssue {
+					t.Errorf("diags[%d].Issue = %q, want %q", i, diags[i].Issue, tt.wantIssue)
+				}
+				if diags[i].Line == 0 {
+					t.Errorf("diags[%d].Line = 0, want the yaml node's line number", i)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapYAMLErrorIncludesExcerpt(t *testing.T) {
+	raw := []byte("x: not-an-int\n")
+
+	var v struct {
+		X int `yaml:"x"`
+	}
+	err := yaml.Unmarshal(raw, &v)
+	if err == nil {
+		t.Fatalf("expected yaml.Unmarshal to fail decoding %q into an int field", raw)
+	}
+	if _, ok := err.(*yaml.TypeError); !ok {
+		t.Fatalf("expected a *yaml.TypeError, got %T: %v", err, err)
+	}
+
+	wrapped := wrapYAMLError("components.yaml", raw, err)
+
+	if !strings.Contains(wrapped.Error(), "components.yaml") {
+		t.Errorf("wrapYAMLError() = %q, want it to mention the filename", wrapped)
+	}
+	if !strings.Contains(wrapped.Error(), "x: not-an-int") {
+		t.Errorf("wrapYAMLError() = %q, want it to include a source excerpt of the offending line", wrapped)
+	}
+	if !strings.Contains(wrapped.Error(), err.Error()) {
+		t.Errorf("wrapYAMLError() = %q, want it to include the original error %q", wrapped, err)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Exporter is where processTemplate sends the formatted output of a
+// template, decoupling rendering from where the result ends up: next to
+// the template (the historical behavior), mirrored into another directory,
+// bundled into a tar archive, or concatenated to stdout.
+type Exporter interface {
+	// Export is called once per processed template. inPlacePath is where the
+	// output would have been written historically (next to the template);
+	// relPath is that same path made relative to the input root being
+	// walked, for exporters that mirror a directory tree or label entries.
+	Export(inPlacePath, relPath string, content []byte) error
+	Close() error
+}
+
+// parseOutputSpec parses a comma-separated list of key=value pairs, the
+// same shape buildx uses for --output, e.g. "type=local,dest=./out".
+func parseOutputSpec(spec string) (map[string]string, error) {
+	opts := make(map[string]string)
+	for _, field := range strings.Split(spec, ",") {
+		if field == "" {
+			continue
+		}
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid output field %q, expected key=value", field)
+		}
+		opts[kv[0]] = kv[1]
+	}
+	if opts["type"] == "" {
+		return nil, fmt.Errorf("output spec %q is missing a type", spec)
+	}
+	return opts, nil
+}
+
+// validateWatchOutputSpec rejects --output types that can't be rebuilt
+// correctly in --watch mode. tar and stdout are single-pass writers: each
+// rebuild would just append another copy of the regenerated file to the
+// same stream instead of replacing it, and the tar archive's closing
+// blocks are only written on a graceful exp.Close(), which --watch has no
+// way to guarantee on an interrupt. local and the default in-place mode
+// don't have either problem, since each rebuild overwrites the same path.
+func validateWatchOutputSpec(spec string) error {
+	if spec == "" {
+		return nil
+	}
+
+	opts, err := parseOutputSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	switch opts["type"] {
+	case "tar", "stdout":
+		return fmt.Errorf("--output type=%s can't be rebuilt incrementally; use type=local or the default in-place output with --watch", opts["type"])
+	}
+	return nil
+}
+
+// newExporter builds the Exporter selected by --output. An empty spec
+// preserves the historical in-place behavior.
+func newExporter(spec string) (Exporter, error) {
+	if spec == "" {
+		return &inPlaceExporter{}, nil
+	}
+
+	opts, err := parseOutputSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts["type"] {
+	case "local":
+		dest := opts["dest"]
+		if dest == "" {
+			dest = "."
+		}
+		return &localExporter{dest: dest}, nil
+	case "tar":
+		dest := opts["dest"]
+		if dest == "" {
+			dest = "-"
+		}
+
+		var w io.Writer
+		var closer io.Closer
+		if dest == "-" {
+			w = os.Stdout
+		} else {
+			f, err := os.Create(dest)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create tar output %s: %v", dest, err)
+			}
+			w, closer = f, f
+		}
+		return &tarExporter{tw: tar.NewWriter(w), closer: closer}, nil
+	case "stdout":
+		return &stdoutExporter{w: os.Stdout}, nil
+	default:
+		return nil, fmt.Errorf("unknown output type %q", opts["type"])
+	}
+}
+
+// inPlaceExporter writes the generated .dhall file next to its template,
+// matching dhallie's original behavior.
+type inPlaceExporter struct{}
+
+func (e *inPlaceExporter) Export(inPlacePath, relPath string, content []byte) error {
+	return ioutil.WriteFile(inPlacePath, content, 0644)
+}
+
+func (e *inPlaceExporter) Close() error { return nil }
+
+// localExporter writes files into dest, mirroring the directory structure
+// of the input root instead of writing next to the template.
+type localExporter struct {
+	dest string
+}
+
+func (e *localExporter) Export(inPlacePath, relPath string, content []byte) error {
+	out := filepath.Join(e.dest, relPath)
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, content, 0644)
+}
+
+func (e *localExporter) Close() error { return nil }
+
+// tarExporter streams every generated file as a single tar archive, to a
+// path or to stdout when dest is "-".
+type tarExporter struct {
+	tw     *tar.Writer
+	closer io.Closer
+}
+
+func (e *tarExporter) Export(inPlacePath, relPath string, content []byte) error {
+	hdr := &tar.Header{
+		Name: filepath.ToSlash(relPath),
+		Mode: 0644,
+		Size: int64(len(content)),
+	}
+	if err := e.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := e.tw.Write(content)
+	return err
+}
+
+func (e *tarExporter) Close() error {
+	if err := e.tw.Close(); err != nil {
+		return err
+	}
+	if e.closer != nil {
+		return e.closer.Close()
+	}
+	return nil
+}
+
+// stdoutExporter concatenates every generated file to stdout behind a
+// header naming its path, for piping into review tools or CI.
+type stdoutExporter struct {
+	w io.Writer
+}
+
+func (e *stdoutExporter) Export(inPlacePath, relPath string, content []byte) error {
+	if _, err := fmt.Fprintf(e.w, "-- file: %s\n", relPath); err != nil {
+		return err
+	}
+	_, err := e.w.Write(content)
+	return err
+}
+
+func (e *stdoutExporter) Close() error { return nil }
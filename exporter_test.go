@@ -0,0 +1,180 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "type only",
+			spec: "type=stdout",
+			want: map[string]string{"type": "stdout"},
+		},
+		{
+			name: "type and dest",
+			spec: "type=local,dest=./out",
+			want: map[string]string{"type": "local", "dest": "./out"},
+		},
+		{
+			name:    "missing type",
+			spec:    "dest=./out",
+			wantErr: true,
+		},
+		{
+			name:    "field without equals",
+			spec:    "type=local,dest",
+			wantErr: true,
+		},
+		{
+			name: "value containing an equals sign",
+			spec: "type=local,dest=./out=copy",
+			want: map[string]string{"type": "local", "dest": "./out=copy"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOutputSpec(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseOutputSpec(%q) error = nil, want an error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseOutputSpec(%q) error = %v", tt.spec, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseOutputSpec(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWatchOutputSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{name: "empty spec (in-place)", spec: ""},
+		{name: "local", spec: "type=local,dest=./out"},
+		{name: "tar", spec: "type=tar,dest=-", wantErr: true},
+		{name: "stdout", spec: "type=stdout", wantErr: true},
+		{name: "invalid spec", spec: "dest=./out", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateWatchOutputSpec(tt.spec)
+			if tt.wantErr && err == nil {
+				t.Fatalf("validateWatchOutputSpec(%q) error = nil, want an error", tt.spec)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("validateWatchOutputSpec(%q) error = %v", tt.spec, err)
+			}
+		})
+	}
+}
+
+func TestLocalExporterMirrorsRelPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exporter-test-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := &localExporter{dest: dir}
+	if err := e.Export("/ignored/in-place/path", filepath.Join("sub", "deploy.dhall"), []byte("content")); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	got, err := ioutil.ReadFile(filepath.Join(dir, "sub", "deploy.dhall"))
+	if err != nil {
+		t.Fatalf("failed to read exported file: %v", err)
+	}
+	if string(got) != "content" {
+		t.Errorf("exported content = %q, want %q", got, "content")
+	}
+
+	if err := e.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestTarExporterWritesEntries(t *testing.T) {
+	var buf bytes.Buffer
+	e := &tarExporter{tw: tar.NewWriter(&buf)}
+
+	if err := e.Export("/ignored", "web/deploy.dhall", []byte("one")); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if err := e.Export("/ignored", "cache/deploy.dhall", []byte("two")); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	tr := tar.NewReader(&buf)
+	var names []string
+	contents := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar.Reader.Next() error = %v", err)
+		}
+		names = append(names, hdr.Name)
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("failed to read tar entry %s: %v", hdr.Name, err)
+		}
+		contents[hdr.Name] = string(content)
+	}
+
+	wantNames := []string{"web/deploy.dhall", "cache/deploy.dhall"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("tar entry names = %v, want %v", names, wantNames)
+	}
+	if contents["web/deploy.dhall"] != "one" || contents["cache/deploy.dhall"] != "two" {
+		t.Errorf("tar entry contents = %v, want web/deploy.dhall=one, cache/deploy.dhall=two", contents)
+	}
+}
+
+func TestNewExporterSelectsType(t *testing.T) {
+	e, err := newExporter("")
+	if err != nil {
+		t.Fatalf("newExporter(\"\") error = %v", err)
+	}
+	if _, ok := e.(*inPlaceExporter); !ok {
+		t.Errorf("newExporter(\"\") = %T, want *inPlaceExporter", e)
+	}
+
+	e, err = newExporter("type=stdout")
+	if err != nil {
+		t.Fatalf("newExporter(\"type=stdout\") error = %v", err)
+	}
+	if _, ok := e.(*stdoutExporter); !ok {
+		t.Errorf("newExporter(\"type=stdout\") = %T, want *stdoutExporter", e)
+	}
+
+	if _, err := newExporter("type=bogus"); err == nil {
+		t.Errorf("newExporter(\"type=bogus\") error = nil, want an error")
+	}
+}
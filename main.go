@@ -3,17 +3,29 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"text/template"
+	"time"
 
+	"github.com/bep/debounce"
+	"github.com/fsnotify/fsnotify"
 	"github.com/inconshreveable/log15"
 	flag "github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
+
+	"github.com/uwedeportivo/dhallie/cache"
+	"github.com/uwedeportivo/dhallie/components"
 )
 
 var (
@@ -27,12 +39,39 @@ var (
 
 	printHelp    bool
 	printVersion bool
+
+	watchMode  bool
+	watchDelay time.Duration
+
+	noCache  bool
+	cacheDir string
+	cacheTTL time.Duration
+
+	outputSpec string
+
+	printDataFormat string
+	dryRun          bool
+
+	dhallRunner string
+	dhallImage  string
+
+	resolvedDhallRunner string
 )
 
 func init() {
 	flag.StringVarP(&componentsFile, "components", "c", "", "(required) components yaml file")
 	flag.BoolVarP(&printHelp, "help", "h", false, "print usage instructions")
 	flag.BoolVar(&printVersion, "version", false, "print version information")
+	flag.BoolVarP(&watchMode, "watch", "w", false, "watch the components file and templates, regenerating on change")
+	flag.DurationVar(&watchDelay, "watch-delay", 200*time.Millisecond, "debounce delay for watch mode")
+	flag.BoolVar(&noCache, "no-cache", false, "disable the build cache and always regenerate output")
+	flag.StringVar(&cacheDir, "cache-dir", "", "build cache directory (default $XDG_CACHE_HOME/dhallie)")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 30*24*time.Hour, "max age of entries kept by 'dhallie cache prune'")
+	flag.StringVar(&outputSpec, "output", "", "where to send generated files, e.g. type=local,dest=./out, type=tar,dest=-, type=stdout (default: next to each template)")
+	flag.StringVar(&printDataFormat, "print-data", "", "print the resolved template data to stdout as json or yaml and exit, without touching any templates")
+	flag.BoolVar(&dryRun, "dry-run", false, "render templates without writing or formatting output")
+	flag.StringVar(&dhallRunner, "dhall-runner", "local", "how to run dhall format: local, docker, or podman")
+	flag.StringVar(&dhallImage, "dhall-image", "dhallhaskell/dhall", "container image to use when --dhall-runner is docker or podman")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of dhallie: \n")
@@ -71,160 +110,540 @@ func logFatal(message string, ctx ...interface{}) {
 }
 
 func loadComponents(filename string) (map[string]interface{}, error) {
-	f, err := os.Open(filename)
+	raw, err := ioutil.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	br := bufio.NewReader(f)
-	decoder := yaml.NewDecoder(br)
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, wrapYAMLError(filename, raw, err)
+	}
+
+	for _, diag := range validateComponents(&root) {
+		log15.Warn("invalid components entry, skipping", "file", filename, "entry", diag.String())
+	}
 
 	comps := make(map[string]interface{})
-	err = decoder.Decode(&comps)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode yaml file: %s: %v", filename, err)
+	if err := root.Decode(&comps); err != nil {
+		return nil, wrapYAMLError(filename, raw, err)
 	}
 	return comps, nil
 }
 
-func executeTemplate(tmpl *template.Template, data interface{}, outfilename string) error {
-	out, err := os.Create(outfilename)
+// runPrintData loads components, resolves the TemplateData every
+// .dhall-template would see, and writes it to stdout in the requested
+// format, without discovering or touching a single template.
+func runPrintData(format string) {
+	if format != "json" && format != "yaml" {
+		logFatal("invalid --print-data format", "format", format, "valid", "json, yaml")
+	}
+
+	comps, err := loadComponents(componentsFile)
 	if err != nil {
-		return err
+		logFatal("failed to load components", "components", componentsFile, "error", err)
 	}
-	defer out.Close()
 
-	bout := bufio.NewWriter(out)
-	defer bout.Flush()
+	data := components.Resolve(comps)
 
-	return tmpl.Execute(bout, data)
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(data); err != nil {
+			logFatal("failed to encode template data as json", "error", err)
+		}
+	case "yaml":
+		out, err := yaml.Marshal(data)
+		if err != nil {
+			logFatal("failed to encode template data as yaml", "error", err)
+		}
+		if _, err := os.Stdout.Write(out); err != nil {
+			logFatal("failed to write template data", "error", err)
+		}
+	}
 }
 
-type ContainerTuple struct {
-	Component     string
-	Name          string
-	ContainerName string
-	Identifier    string
+func executeTemplate(tmpl *template.Template, templatePath string, data interface{}, w io.Writer) error {
+	if err := tmpl.Execute(w, data); err != nil {
+		raw, readErr := ioutil.ReadFile(templatePath)
+		if readErr != nil {
+			return err
+		}
+		return wrapTemplateError(templatePath, raw, err)
+	}
+	return nil
 }
 
-type KindTuple struct {
-	Component  string
-	Name       string
-	Kind       string
-	Identifier string
+// runnerAvailable reports whether the given --dhall-runner value has its
+// required binary on PATH: the dhall binary itself for "local", or the
+// container engine for "docker"/"podman".
+func runnerAvailable(runner string) bool {
+	switch runner {
+	case "local":
+		_, err := exec.LookPath("dhall")
+		return err == nil
+	case "docker", "podman":
+		_, err := exec.LookPath(runner)
+		return err == nil
+	default:
+		return false
+	}
 }
 
-type TemplateData struct {
-	DeploymentTuples  []*ContainerTuple
-	StatefulSetTuples []*ContainerTuple
-	KindTuples        []*KindTuple
+// resolveDhallRunner picks the --dhall-runner to actually use, falling back
+// to whichever of local/docker/podman is available and logging why, rather
+// than failing on every invocation of dhallFormat.
+func resolveDhallRunner() string {
+	if runnerAvailable(dhallRunner) {
+		return dhallRunner
+	}
+
+	log15.Warn("requested dhall runner not available, looking for a fallback", "runner", dhallRunner)
+	for _, candidate := range []string{"local", "docker", "podman"} {
+		if runnerAvailable(candidate) {
+			log15.Warn("falling back to dhall runner", "runner", candidate)
+			return candidate
+		}
+	}
+
+	logFatal("no dhall runner available", "tried", "local, docker, podman")
+	return ""
 }
 
-func containerTuples(targetKind string, comps map[string]interface{}) []*ContainerTuple {
-	var result []*ContainerTuple
+func dhallFormat(file string) error {
+	if resolvedDhallRunner == "local" {
+		cmd := exec.Command("dhall", "format", "--inplace", file)
+		cmd.Stderr = os.Stderr
+		return cmd.Run()
+	}
 
-	for comp, compData := range comps {
-		compDataM, ok := compData.(map[string]interface{})
-		if !ok {
-			continue
-		}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("failed to resolve absolute path for %s: %v", file, err)
+	}
+	dir := filepath.Dir(abs)
+	base := filepath.Base(abs)
 
-		for kind, kindData := range compDataM {
-			if kind != targetKind {
-				continue
-			}
-			kindDataM, ok := kindData.(map[string]interface{})
-			if !ok {
-				continue
-			}
+	cmd := exec.Command(resolvedDhallRunner, "run", "--rm",
+		"-v", fmt.Sprintf("%s:/work", dir),
+		dhallImage,
+		"dhall", "format", "--inplace", "/work/"+base)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
 
-			for name, nameData := range kindDataM {
-				nameDataM, ok := nameData.(map[string]interface{})
-				if !ok {
-					continue
-				}
+var (
+	dhallVersionOnce   sync.Once
+	dhallVersionCached string
+)
 
-				for section, sectionData := range nameDataM {
-					if section != "containers" {
-						continue
-					}
-
-					sectionDataM, ok := sectionData.(map[string]interface{})
-					if !ok {
-						continue
-					}
-
-					for containerName := range sectionDataM {
-						result = append(result, &ContainerTuple{
-							Component:     comp,
-							Name:          name,
-							ContainerName: containerName,
-							Identifier:    fmt.Sprintf("f%d", len(result)),
-						})
-					}
-				}
-			}
+// dhallVersion reports the version of the dhall binary that dhallFormat
+// would actually invoke, so callers don't need to know whether that's a
+// local install or one running inside a container. It's resolved once per
+// run and cached: templateCacheKey calls it for every template, and for a
+// containerized runner that would otherwise mean a `docker run` just to
+// check a version that can't change mid-run.
+func dhallVersion() string {
+	dhallVersionOnce.Do(func() {
+		dhallVersionCached = resolveDhallVersion()
+	})
+	return dhallVersionCached
+}
+
+func resolveDhallVersion() string {
+	if resolvedDhallRunner == "local" {
+		out, err := exec.Command("dhall", "--version").Output()
+		if err != nil {
+			return "unknown"
 		}
+		return strings.TrimSpace(string(out))
 	}
-	return result
+
+	out, err := exec.Command(resolvedDhallRunner, "run", "--rm", dhallImage, "dhall", "--version").Output()
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(out))
 }
 
-func kindTuples(comps map[string]interface{}) []*KindTuple {
-	var result []*KindTuple
+// templateCacheKey hashes the template's contents, the resolved
+// TemplateData it would be rendered with, and the dhall runner that would
+// format it (binary version, plus the runner and image when containerized),
+// so a cache hit implies the formatted output would come out identical.
+func templateCacheKey(templatePath string, data *components.TemplateData) (string, error) {
+	tmplBytes, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return "", err
+	}
 
-	for comp, compData := range comps {
-		compDataM, ok := compData.(map[string]interface{})
-		if !ok {
-			continue
-		}
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
 
-		for kind, kindData := range compDataM {
-			kindDataM, ok := kindData.(map[string]interface{})
-			if !ok {
-				continue
-			}
+	h := sha256.New()
+	h.Write(tmplBytes)
+	h.Write(dataBytes)
+	h.Write([]byte(resolvedDhallRunner))
+	if resolvedDhallRunner != "local" {
+		h.Write([]byte(dhallImage))
+	}
+	h.Write([]byte(dhallVersion()))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-			for name := range kindDataM {
-				result = append(result, &KindTuple{
-					Component:  comp,
-					Kind:       kind,
-					Name:       name,
-					Identifier: fmt.Sprintf("f%d", len(result)),
-				})
-			}
-		}
+// renderAndFormat executes tmpl into a scratch file, runs dhall format in
+// place on it, and returns the formatted bytes. Rendering always goes
+// through a scratch file because dhallFormat only knows how to format a
+// file on disk; where the result is ultimately written is up to the
+// Exporter passed to processTemplate.
+func renderAndFormat(tmpl *template.Template, templatePath string, data *components.TemplateData) ([]byte, error) {
+	// Scratch files live in their own private directory, not the shared OS
+	// temp directory: dhallFormat mounts that directory read-write into the
+	// container when --dhall-runner is docker/podman, and a directory
+	// shared with every other process's temp files would mount far more
+	// than the one file being formatted.
+	scratchDir, err := ioutil.TempDir("", "dhallie-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(scratchDir)
+	scratchPath := filepath.Join(scratchDir, "scratch.dhall")
+
+	out, err := os.Create(scratchPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scratch file: %v", err)
+	}
+	bout := bufio.NewWriter(out)
+	execErr := executeTemplate(tmpl, templatePath, data, bout)
+	flushErr := bout.Flush()
+	out.Close()
+	if execErr != nil {
+		return nil, fmt.Errorf("failed to render template: %v", execErr)
 	}
-	return result
+	if flushErr != nil {
+		return nil, fmt.Errorf("failed to render template: %v", flushErr)
+	}
+
+	if err := dhallFormat(scratchPath); err != nil {
+		return nil, fmt.Errorf("failed to format rendered template: %v", err)
+	}
+
+	return ioutil.ReadFile(scratchPath)
 }
 
-func dhallFormat(file string) error {
-	cmd := exec.Command("dhall", "format", "--inplace", file)
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// renderOnly executes tmpl into memory without writing a scratch file or
+// running dhall format, for --dry-run.
+func renderOnly(tmpl *template.Template, templatePath string, data *components.TemplateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := executeTemplate(tmpl, templatePath, data, &buf); err != nil {
+		return nil, fmt.Errorf("failed to render template: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func resolveCacheDir() (string, error) {
+	if cacheDir != "" {
+		return cacheDir, nil
+	}
+	return cache.DefaultDir()
 }
 
-func processTemplate(templatePath string, data *TemplateData) error {
+// openCacheStore returns nil, nil when caching is disabled via --no-cache,
+// so callers can pass the result straight to processTemplate.
+func openCacheStore() (*cache.Store, error) {
+	if noCache {
+		return nil, nil
+	}
+
+	dir, err := resolveCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve cache directory: %v", err)
+	}
+	return cache.NewStore(dir)
+}
+
+func runCachePrune() {
+	dir, err := resolveCacheDir()
+	if err != nil {
+		logFatal("failed to resolve cache directory", "error", err)
+	}
+
+	store, err := cache.NewStore(dir)
+	if err != nil {
+		logFatal("failed to open build cache", "dir", dir, "error", err)
+	}
+
+	if err := store.Prune(cacheTTL); err != nil {
+		logFatal("failed to prune build cache", "dir", dir, "error", err)
+	}
+
+	log15.Info("pruned build cache", "dir", dir, "ttl", cacheTTL)
+}
+
+func processTemplate(templatePath, root string, data *components.TemplateData, store *cache.Store, exp Exporter) error {
 	tmpl, err := template.ParseFiles(templatePath)
 	if err != nil {
-		return fmt.Errorf("failed to parse template file %s: %v", templatePath, err)
+		raw, readErr := ioutil.ReadFile(templatePath)
+		if readErr != nil {
+			return fmt.Errorf("failed to parse template file %s: %v", templatePath, err)
+		}
+		return wrapTemplateError(templatePath, raw, err)
+	}
+
+	if dryRun {
+		if _, err := renderOnly(tmpl, templatePath, data); err != nil {
+			return err
+		}
+		log15.Info("dry run: rendered without writing or formatting", "path", templatePath)
+		return nil
 	}
 
 	outPath := strings.TrimSuffix(templatePath, filepath.Ext(templatePath))
 	outPath = outPath + ".dhall"
 
-	err = executeTemplate(tmpl, data, outPath)
-	if err != nil {
-		return fmt.Errorf("failed to write output %s: %v", outPath, err)
+	relPath := filepath.Base(outPath)
+	if info, statErr := os.Stat(root); statErr == nil && info.IsDir() {
+		if rel, err := filepath.Rel(root, outPath); err == nil {
+			relPath = rel
+		}
 	}
 
-	err = dhallFormat(outPath)
+	var formatted []byte
+	if store == nil {
+		formatted, err = renderAndFormat(tmpl, templatePath, data)
+	} else {
+		var key string
+		key, err = templateCacheKey(templatePath, data)
+		if err != nil {
+			return fmt.Errorf("failed to compute cache key for %s: %v", templatePath, err)
+		}
+
+		formatted, err = store.GetOrCreate(key, func(w io.Writer) error {
+			b, err := renderAndFormat(tmpl, templatePath, data)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		})
+	}
 	if err != nil {
-		return fmt.Errorf("failed to format output %s: %v", outPath, err)
+		return err
+	}
+
+	if err := exp.Export(outPath, relPath, formatted); err != nil {
+		return fmt.Errorf("failed to export output for %s: %v", templatePath, err)
 	}
 	return nil
 }
 
+// componentsStamp captures enough of a file's metadata to detect edits
+// without hashing its contents on every fsnotify event.
+type componentsStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func isWatchedTemplate(watchedTemplates map[string]string, path string) bool {
+	_, ok := watchedTemplates[path]
+	return ok
+}
+
+func statComponents(filename string) (componentsStamp, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return componentsStamp{}, err
+	}
+	return componentsStamp{size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// rootFor picks the input path is under. filepath.Rel is used instead of
+// strings.HasPrefix(path, input) so that sibling roots where one is a
+// string-prefix of another (e.g. "foo" and "foobar") don't cause a
+// template under foobar/ to resolve against foo. If path isn't under any
+// input, it falls back to path's own directory.
+func rootFor(inputs []string, path string) string {
+	for _, input := range inputs {
+		rel, err := filepath.Rel(input, path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return input
+		}
+	}
+	return filepath.Dir(path)
+}
+
+// watchTemplates re-runs processTemplate for every .dhall-template under
+// inputs whenever it or the components file changes, until interrupted.
+// Template errors are logged and watching continues, mirroring Hugo's
+// server-mode rebuild loop rather than exiting like a one-shot run.
+func watchTemplates(inputs []string, store *cache.Store, exp Exporter) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(componentsFile); err != nil {
+		return fmt.Errorf("failed to watch components file %s: %v", componentsFile, err)
+	}
+
+	watchedDirs := make(map[string]bool)
+	watchedTemplates := make(map[string]string) // template path -> input root
+	addTemplate := func(path string) {
+		if _, ok := watchedTemplates[path]; ok {
+			return
+		}
+		dir := filepath.Dir(path)
+		if !watchedDirs[dir] {
+			if err := watcher.Add(dir); err != nil {
+				log15.Error("failed to watch directory", "dir", dir, "error", err)
+				return
+			}
+			watchedDirs[dir] = true
+		}
+		watchedTemplates[path] = rootFor(inputs, path)
+	}
+
+	for _, input := range inputs {
+		err := filepath.Walk(input, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && filepath.Ext(path) == ".dhall-template" {
+				addTemplate(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s for templates: %v", input, err)
+		}
+	}
+
+	comps, err := loadComponents(componentsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load components: %v", err)
+	}
+	stamp, err := statComponents(componentsFile)
+	if err != nil {
+		return fmt.Errorf("failed to stat components file: %v", err)
+	}
+
+	data := components.Resolve(comps)
+
+	rebuildAll := func() {
+		for path, root := range watchedTemplates {
+			if err := processTemplate(path, root, data, store, exp); err != nil {
+				log15.Error("failed to process template", "path", path, "error", err)
+				continue
+			}
+			log15.Info("regenerated", "path", path)
+		}
+	}
+
+	rebuildOne := func(path string) {
+		if err := processTemplate(path, watchedTemplates[path], data, store, exp); err != nil {
+			log15.Error("failed to process template", "path", path, "error", err)
+			return
+		}
+		log15.Info("regenerated", "path", path)
+	}
+
+	// bep/debounce runs each debounced call on its own goroutine
+	// (time.AfterFunc), so a debounced closure must never touch data,
+	// comps, stamp, or watchedTemplates directly — those are owned by the
+	// select loop below. Each debounced callback instead only sends a
+	// rebuildEvent describing what happened; the select loop is the sole
+	// goroutine that reads or mutates the shared state, so no mutex is
+	// needed. A single debounce instance is also last-call-wins (a call
+	// cancels and replaces any pending one on the same instance), so
+	// components and each template path still get their own debouncer to
+	// avoid one edit silently dropping the other's rebuild.
+	type rebuildEvent struct {
+		components bool
+		path       string
+		isNew      bool
+	}
+	rebuilds := make(chan rebuildEvent)
+
+	debouncedComponents := debounce.New(watchDelay)
+	templateDebouncers := make(map[string]func(func()))
+	debouncedForTemplate := func(path string) func(func()) {
+		d, ok := templateDebouncers[path]
+		if !ok {
+			d = debounce.New(watchDelay)
+			templateDebouncers[path] = d
+		}
+		return d
+	}
+
+	log15.Info("watching for changes", "components", componentsFile, "templates", len(watchedTemplates))
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+
+			path := event.Name
+			switch {
+			case path == componentsFile:
+				debouncedComponents(func() {
+					rebuilds <- rebuildEvent{components: true}
+				})
+			case isWatchedTemplate(watchedTemplates, path):
+				debouncedForTemplate(path)(func() {
+					rebuilds <- rebuildEvent{path: path}
+				})
+			case filepath.Ext(path) == ".dhall-template":
+				debouncedForTemplate(path)(func() {
+					rebuilds <- rebuildEvent{path: path, isNew: true}
+				})
+			}
+		case ev := <-rebuilds:
+			switch {
+			case ev.components:
+				newStamp, err := statComponents(componentsFile)
+				if err != nil {
+					log15.Error("failed to stat components file", "error", err)
+					continue
+				}
+				if newStamp == stamp {
+					continue
+				}
+				stamp = newStamp
+
+				newComps, err := loadComponents(componentsFile)
+				if err != nil {
+					log15.Error("failed to reload components", "error", err)
+					continue
+				}
+				comps = newComps
+				data = components.Resolve(comps)
+				rebuildAll()
+			case ev.isNew:
+				addTemplate(ev.path)
+				rebuildOne(ev.path)
+			default:
+				rebuildOne(ev.path)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log15.Error("watcher error", "error", err)
+		}
+	}
+}
+
 func main() {
 	log15.Root().SetHandler(log15.StreamHandler(os.Stdout, log15.LogfmtFormat()))
 
@@ -241,14 +660,33 @@ func main() {
 		os.Exit(0)
 	}
 
+	if args := flag.Args(); len(args) > 0 && args[0] == "cache" {
+		if len(args) != 2 || args[1] != "prune" {
+			fmt.Fprintln(os.Stderr, "usage: dhallie cache prune")
+			os.Exit(1)
+		}
+		runCachePrune()
+		os.Exit(0)
+	}
+
 	if componentsFile == "" {
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	comps, err := loadComponents(componentsFile)
-	if err != nil {
-		logFatal("failed to load components", "components", componentsFile, "error", err)
+	if printDataFormat != "" {
+		runPrintData(printDataFormat)
+		os.Exit(0)
+	}
+
+	if watchMode && !dryRun {
+		if err := validateWatchOutputSpec(outputSpec); err != nil {
+			logFatal("invalid --output for --watch", "output", outputSpec, "error", err)
+		}
+	}
+
+	if !dryRun {
+		resolvedDhallRunner = resolveDhallRunner()
 	}
 
 	inputs := flag.Args()
@@ -260,12 +698,43 @@ func main() {
 		inputs = []string{cwd}
 	}
 
-	data := &TemplateData{
-		DeploymentTuples:  containerTuples("Deployment", comps),
-		StatefulSetTuples: containerTuples("StatefulSet", comps),
-		KindTuples:        kindTuples(comps),
+	// In --dry-run mode, processTemplate never calls dhallFormat, store, or
+	// exp, so none of them need to be resolved: dry-run has no dependency
+	// on a local dhall binary, a cache directory, or an output destination.
+	var store *cache.Store
+	var exp Exporter
+	if !dryRun {
+		var err error
+		store, err = openCacheStore()
+		if err != nil {
+			logFatal("failed to open build cache", "error", err)
+		}
+
+		exp, err = newExporter(outputSpec)
+		if err != nil {
+			logFatal("failed to set up output", "output", outputSpec, "error", err)
+		}
+		defer func() {
+			if err := exp.Close(); err != nil {
+				logFatal("failed to close output", "output", outputSpec, "error", err)
+			}
+		}()
+	}
+
+	if watchMode {
+		if err := watchTemplates(inputs, store, exp); err != nil {
+			logFatal("watch mode failed", "error", err)
+		}
+		return
 	}
 
+	comps, err := loadComponents(componentsFile)
+	if err != nil {
+		logFatal("failed to load components", "components", componentsFile, "error", err)
+	}
+
+	data := components.Resolve(comps)
+
 	for _, input := range inputs {
 		err = filepath.Walk(input, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
@@ -277,7 +746,7 @@ func main() {
 			}
 
 			if filepath.Ext(path) == ".dhall-template" {
-				err = processTemplate(path, data)
+				err = processTemplate(path, input, data, store, exp)
 				if err != nil {
 					return fmt.Errorf("failed to process %s: %v", path, err)
 				}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRootFor(t *testing.T) {
+	foo := filepath.Join("a", "foo")
+	foobar := filepath.Join("a", "foobar")
+
+	tests := []struct {
+		name   string
+		inputs []string
+		path   string
+		want   string
+	}{
+		{
+			name:   "sibling root that is a string-prefix of another",
+			inputs: []string{foo, foobar},
+			path:   filepath.Join(foobar, "tmpl.dhall-template"),
+			want:   foobar,
+		},
+		{
+			name:   "path under the shorter of two prefix-sharing roots",
+			inputs: []string{foo, foobar},
+			path:   filepath.Join(foo, "tmpl.dhall-template"),
+			want:   foo,
+		},
+		{
+			name:   "path equal to an input",
+			inputs: []string{foo, foobar},
+			path:   foo,
+			want:   foo,
+		},
+		{
+			name:   "path outside all inputs falls back to its own directory",
+			inputs: []string{foo, foobar},
+			path:   filepath.Join("b", "tmpl.dhall-template"),
+			want:   "b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rootFor(tt.inputs, tt.path); got != tt.want {
+				t.Errorf("rootFor(%v, %q) = %q, want %q", tt.inputs, tt.path, got, tt.want)
+			}
+		})
+	}
+}